@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/wader/goutubedl"
 	"github.com/wader/ydls/internal/ydls"
@@ -28,6 +29,23 @@ var serverFlag = flag.Bool("server", false, "Start server")
 var listenFlag = flag.String("listen", ":8080", "Listen address")
 var indexFlag = flag.String("index", "", "Path to index template")
 var noProgressFlag = flag.Bool("noprogress", false, "Don't print download progress")
+var workersFlag = flag.Int("workers", 4, "Worker pool size for /api/batch jobs")
+var jobTTLFlag = flag.Duration("job-ttl", 0, "Free a finished /api/batch job after this much inactivity (0 = 10m default)")
+
+var cacheDirFlag = flag.String("cache-dir", "", "Cache completed downloads under this directory")
+var cacheMaxBytesFlag = flag.Int64("cache-max-bytes", 0, "Evict oldest cache entries above this size (0 = unlimited)")
+var cacheTTLFlag = flag.Duration("cache-ttl", 0, "Expire cache entries older than this (0 = never)")
+var adminTokenFlag = flag.String("admin-token", "", "Bearer token required for /cache admin endpoints")
+
+var infoCacheTTLFlag = flag.Duration("info-cache-ttl", 0, "Cache GET /info results for this long (0 = 10m default)")
+
+var playlistFlag = flag.Bool("playlist", false, "Download URL as a playlist, writing a zip archive")
+
+var logFormatFlag = flag.String("log-format", "text", "Access log format: text or json")
+var accessLogFlag = flag.String("access-log", "", "Write access log to this path instead of stdout")
+
+var hlsFlag = flag.Bool("hls", false, "Enable /hls/... live-remuxing endpoints")
+var hlsIdleTimeoutFlag = flag.Duration("hls-idle-timeout", 2*time.Minute, "Free an HLS session after this much inactivity")
 
 func fatalIfErrorf(err error, format string, a ...interface{}) {
 	if err != nil {
@@ -170,7 +188,18 @@ func server(y ydls.YDLS) {
 	fatalIfErrorf(err, "failed to get yt-dlp version")
 	log.Printf("yt-dlp %s", ytdlpVersion)
 
-	yh := &ydls.Handler{YDLS: y}
+	if *cacheDirFlag != "" {
+		cache, err := ydls.NewFileCache(*cacheDirFlag, *cacheMaxBytesFlag, *cacheTTLFlag)
+		fatalIfErrorf(err, "failed to open cache dir")
+		y.Config.Cache = cache
+	}
+	y.Config.AdminToken = *adminTokenFlag
+
+	yh := &ydls.Handler{YDLS: y, InfoCacheTTL: *infoCacheTTLFlag}
+	yh.Jobs = ydls.NewJobs(y, *workersFlag, os.TempDir(), *jobTTLFlag)
+	if *hlsFlag {
+		yh.HLS = ydls.NewHLSSessions(*hlsIdleTimeoutFlag)
+	}
 
 	if *infoFlag {
 		yh.InfoLog = log.New(os.Stdout, "INFO: ", log.Ltime)
@@ -184,22 +213,24 @@ func server(y ydls.YDLS) {
 		yh.IndexTmpl = indexTmpl
 	}
 
-	http.HandleFunc("/convert", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", func(w http.ResponseWriter, r *http.Request) {
 		convertHandler(w, r, y)
 	})
+	mux.Handle("/", yh)
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
-			return
-		}
-		// Render the HTML form
-		w.Header().Set("Content-Type", "text/html")
-		fmt.Fprint(w, htmlForm)
-	})
+	accessLogOut := io.Writer(os.Stdout)
+	if *accessLogFlag != "" {
+		accessLogFile, err := os.OpenFile(*accessLogFlag, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		fatalIfErrorf(err, "failed to open access log")
+		accessLogOut = accessLogFile
+	}
+	accessLog := log.New(accessLogOut, "", 0)
+
+	root := ydls.LoggingMiddleware(mux, accessLog, *logFormatFlag == "json")
 
 	log.Printf("Listening on %s", *listenFlag)
-	if err := http.ListenAndServe(*listenFlag, nil); err != nil {
+	if err := http.ListenAndServe(*listenFlag, root); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -230,6 +261,47 @@ func absRootPath(root string, path string) (string, error) {
 
 
 
+func downloadPlaylist(y ydls.YDLS) {
+	rawURL := flag.Arg(0)
+	if rawURL == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	requestOptions, requestOptionsErr := ydls.NewRequestOptionsFromOpts(flag.Args()[1:], y.Config.Formats)
+	requestOptions.MediaRawURL = rawURL
+	fatalIfErrorf(requestOptionsErr, "format and options")
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	title, entries, err := ydls.PlaylistEntries(ctx, rawURL)
+	fatalIfErrorf(err, "playlist lookup failed")
+
+	results := ydls.DownloadPlaylistEntries(ctx, y, requestOptions, entries, *workersFlag)
+
+	var archiveWriter io.Writer
+	var path string
+	if title == "" {
+		archiveWriter = os.Stdout
+	} else {
+		wd, err := os.Getwd()
+		fatalIfErrorf(err, "getwd")
+		path, err = absRootPath(wd, ydls.SafeArchiveFilename(title)+".zip")
+		fatalIfErrorf(err, "write path")
+
+		archiveFile, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		fatalIfErrorf(err, "failed to open file")
+		defer archiveFile.Close()
+		archiveWriter = archiveFile
+	}
+
+	fatalIfErrorf(ydls.WriteArchive(archiveWriter, ydls.ArchiveZip, results), "archive failed")
+	if path != "" {
+		fmt.Println(path)
+	}
+}
+
 func download(y ydls.YDLS) {
 	var debugLog ydls.Printer
 	if *debugFlag {
@@ -293,6 +365,8 @@ func main() {
 
 	if *serverFlag {
 		server(y)
+	} else if *playlistFlag {
+		downloadPlaylist(y)
 	} else {
 		download(y)
 	}
@@ -0,0 +1,272 @@
+package ydls
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry describes a single finished download stored in a Cache.
+type CacheEntry struct {
+	SHA       string    `json:"sha"`
+	Size      int64     `json:"size"`
+	MIMEType  string    `json:"mime_type"`
+	Filename  string    `json:"filename"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Cache stores and serves completed downloads keyed by a content hash of
+// their request (URL, format, codecs, subs), so repeat conversions of the
+// same input can be served without re-running yt-dlp and ffmpeg.
+type Cache interface {
+	// Get returns the cached content for key, if present. The caller must
+	// close the returned ReadCloser.
+	Get(key string) (io.ReadCloser, CacheEntry, bool)
+	// Put stores r under key with the given metadata, evicting older
+	// entries if the cache is over its configured size.
+	Put(key string, r io.Reader, entry CacheEntry) error
+	// Delete removes the entry for sha, as returned by List.
+	Delete(sha string) error
+	// List returns all entries currently in the cache.
+	List() ([]CacheEntry, error)
+}
+
+// CacheKey hashes the parts of a request that determine its output, borrowing
+// the OID-path idea from Git-LFS: identical requests map to the same key.
+func CacheKey(mediaURL string, format string, codecs []string, subs bool) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%v", mediaURL, format, strings.Join(codecs, ","), subs)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FileCache is a Cache implementation backed by a directory of files named
+// by their SHA-256 key, with a JSON sidecar per entry holding its metadata.
+type FileCache struct {
+	Dir      string
+	MaxBytes int64
+	TTL      time.Duration
+
+	mu sync.Mutex
+}
+
+// NewFileCache creates a FileCache rooted at dir, creating it if needed.
+func NewFileCache(dir string, maxBytes int64, ttl time.Duration) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileCache{Dir: dir, MaxBytes: maxBytes, TTL: ttl}, nil
+}
+
+func (fc *FileCache) dataPath(sha string) string { return filepath.Join(fc.Dir, sha) }
+func (fc *FileCache) metaPath(sha string) string { return filepath.Join(fc.Dir, sha+".json") }
+
+func (fc *FileCache) readMeta(sha string) (CacheEntry, error) {
+	b, err := os.ReadFile(fc.metaPath(sha))
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return CacheEntry{}, err
+	}
+	return entry, nil
+}
+
+// Get implements Cache.
+func (fc *FileCache) Get(key string) (io.ReadCloser, CacheEntry, bool) {
+	entry, err := fc.readMeta(key)
+	if err != nil {
+		return nil, CacheEntry{}, false
+	}
+	if fc.TTL > 0 && time.Since(entry.CreatedAt) > fc.TTL {
+		fc.Delete(key)
+		return nil, CacheEntry{}, false
+	}
+
+	f, err := os.Open(fc.dataPath(key))
+	if err != nil {
+		return nil, CacheEntry{}, false
+	}
+
+	now := time.Now()
+	os.Chtimes(fc.dataPath(key), now, now)
+
+	return f, entry, true
+}
+
+// Put implements Cache.
+func (fc *FileCache) Put(key string, r io.Reader, entry CacheEntry) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	tmp, err := os.CreateTemp(fc.Dir, key+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	entry.SHA = key
+	entry.Size = size
+	entry.CreatedAt = time.Now()
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fc.metaPath(key), b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, fc.dataPath(key)); err != nil {
+		os.Remove(fc.metaPath(key))
+		return err
+	}
+
+	fc.evict()
+	return nil
+}
+
+// Delete implements Cache.
+func (fc *FileCache) Delete(sha string) error {
+	os.Remove(fc.metaPath(sha))
+	return os.Remove(fc.dataPath(sha))
+}
+
+// List implements Cache.
+func (fc *FileCache) List() ([]CacheEntry, error) {
+	matches, err := filepath.Glob(filepath.Join(fc.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]CacheEntry, 0, len(matches))
+	for _, m := range matches {
+		sha := strings.TrimSuffix(filepath.Base(m), ".json")
+		if entry, err := fc.readMeta(sha); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].CreatedAt.After(entries[j].CreatedAt) })
+
+	return entries, nil
+}
+
+// evict removes least-recently-used entries (by data file mtime) until the
+// cache is back under MaxBytes. Caller must hold fc.mu.
+func (fc *FileCache) evict() {
+	if fc.MaxBytes <= 0 {
+		return
+	}
+
+	entries, err := fc.List()
+	if err != nil {
+		return
+	}
+
+	type fileInfo struct {
+		sha     string
+		size    int64
+		modTime time.Time
+	}
+	files := make([]fileInfo, 0, len(entries))
+	var total int64
+	for _, e := range entries {
+		fi, err := os.Stat(fc.dataPath(e.SHA))
+		if err != nil {
+			continue
+		}
+		files = append(files, fileInfo{sha: e.SHA, size: fi.Size(), modTime: fi.ModTime()})
+		total += fi.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	for _, f := range files {
+		if total <= fc.MaxBytes {
+			break
+		}
+		if err := fc.Delete(f.sha); err == nil {
+			total -= f.size
+		}
+	}
+}
+
+// validSHARe matches the lowercase hex SHA-256 keys CacheKey produces; used
+// to reject path-traversal attempts (e.g. "../../etc/passwd") before a sha
+// taken from a URL path reaches filepath.Join in FileCache.
+var validSHARe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// checkBearerToken reports whether r carries the configured admin token.
+func checkBearerToken(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ") == token
+}
+
+// handleCacheList implements GET /cache.
+func (yh *Handler) handleCacheList(w http.ResponseWriter, r *http.Request) {
+	if !checkBearerToken(r, yh.YDLS.Config.AdminToken) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if yh.YDLS.Config.Cache == nil {
+		http.Error(w, "Cache not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	entries, err := yh.YDLS.Config.Cache.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// handleCacheDelete implements DELETE /cache/{sha}.
+func (yh *Handler) handleCacheDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkBearerToken(r, yh.YDLS.Config.AdminToken) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if yh.YDLS.Config.Cache == nil {
+		http.Error(w, "Cache not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	sha := strings.TrimPrefix(r.URL.Path, "/cache/")
+	if !validSHARe.MatchString(sha) {
+		http.Error(w, "Invalid cache key", http.StatusBadRequest)
+		return
+	}
+	if err := yh.YDLS.Config.Cache.Delete(sha); err != nil {
+		http.Error(w, "Unknown cache entry", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,68 @@
+package ydls
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheKeyIsStableAndDistinct(t *testing.T) {
+	a := CacheKey("http://example.com/a", "mp4", []string{"h264"}, false)
+	b := CacheKey("http://example.com/a", "mp4", []string{"h264"}, false)
+	if a != b {
+		t.Errorf("CacheKey() is not stable: %q != %q", a, b)
+	}
+
+	c := CacheKey("http://example.com/b", "mp4", []string{"h264"}, false)
+	if a == c {
+		t.Errorf("CacheKey() collided for different URLs: %q", a)
+	}
+
+	if !validSHARe.MatchString(a) {
+		t.Errorf("CacheKey() = %q, want a 64-char lowercase hex string", a)
+	}
+}
+
+func TestValidSHARe(t *testing.T) {
+	for _, tc := range []struct {
+		sha  string
+		want bool
+	}{
+		{strings.Repeat("a", 64), true},
+		{strings.Repeat("A", 64), false},
+		{"../../etc/passwd", false},
+		{"", false},
+		{strings.Repeat("a", 63), false},
+	} {
+		if got := validSHARe.MatchString(tc.sha); got != tc.want {
+			t.Errorf("validSHARe.MatchString(%q) = %v, want %v", tc.sha, got, tc.want)
+		}
+	}
+}
+
+func TestFileCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	fc, err := NewFileCache(t.TempDir(), 10, 0)
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+
+	put := func(key, data string) {
+		if err := fc.Put(key, strings.NewReader(data), CacheEntry{MIMEType: "application/octet-stream"}); err != nil {
+			t.Fatalf("Put(%q) error = %v", key, err)
+		}
+	}
+
+	oldKey := strings.Repeat("1", 64)
+	newKey := strings.Repeat("2", 64)
+
+	put(oldKey, "0123456789")
+	time.Sleep(10 * time.Millisecond) // ensure distinct mtimes to evict by
+	put(newKey, "9876543210")
+
+	if _, _, ok := fc.Get(oldKey); ok {
+		t.Errorf("Get(oldKey) = ok, want the oldest entry to have been evicted")
+	}
+	if _, _, ok := fc.Get(newKey); !ok {
+		t.Errorf("Get(newKey) = not ok, want the newest entry to still be cached")
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 type baseURLXHeaders int
@@ -66,6 +69,42 @@ type Handler struct {
 	IndexTmpl *template.Template
 	InfoLog   Printer
 	DebugLog  Printer
+	// Jobs, if set, enables the POST /api/batch and GET/DELETE /api/jobs/{id}
+	// asynchronous download endpoints alongside the synchronous path below.
+	Jobs *Jobs
+
+	progressOnce sync.Once
+	progress     *progressRegistry
+
+	// HLS, if set, enables the GET /hls/... live-remuxing endpoints for
+	// progressive playback in <video> tags and HLS-capable clients.
+	HLS *HLSSessions
+
+	// InfoCacheTTL overrides how long GET /info results are cached (0 uses
+	// defaultInfoCacheTTL).
+	InfoCacheTTL  time.Duration
+	infoCacheOnce sync.Once
+	infoCache     *infoCache
+}
+
+// progressReg lazily initializes the registry backing GET /progress/{id}.
+func (yh *Handler) progressReg() *progressRegistry {
+	yh.progressOnce.Do(func() { yh.progress = newProgressRegistry() })
+	return yh.progress
+}
+
+// infoCacheReg lazily initializes the cache backing GET /info, scoped to
+// this Handler so multiple Handlers in one process (e.g. tests) don't share
+// entries.
+func (yh *Handler) infoCacheReg() *infoCache {
+	yh.infoCacheOnce.Do(func() {
+		ttl := yh.InfoCacheTTL
+		if ttl <= 0 {
+			ttl = defaultInfoCacheTTL
+		}
+		yh.infoCache = newInfoCache(ttl)
+	})
+	return yh.infoCache
 }
 
 const htmlForm = `
@@ -116,15 +155,90 @@ const htmlForm = `
 	input[type="submit"]:hover {
 		background-color: #3e8e41;
 	}
+	#progress {
+		margin: 0 auto;
+		max-width: 500px;
+		text-align: center;
+		color: #ccc;
+	}
+	select {
+		width: 100%;
+		padding: 10px;
+		border-radius: 5px;
+		border: none;
+		margin-bottom: 20px;
+	}
     </style>
 </head>
 <body>
     <h1>Video Converter</h1>
-    <form action="/mp4/" method="get">
+    <form id="convertForm" action="/" method="get">
         <label for="url">Video URL:</label>
         <input type="text" id="url" name="url" required>
+        <label for="format">Format:</label>
+        <select id="format" name="format"><option value="">best</option></select>
+        <label for="codec">Codec:</label>
+        <select id="codec" name="codec"><option value="">any</option></select>
         <input type="submit" value="Convert">
     </form>
+    <p id="progress"></p>
+    <script>
+        var urlInput = document.getElementById("url");
+        var formatSelect = document.getElementById("format");
+        var codecSelect = document.getElementById("codec");
+
+        urlInput.addEventListener("blur", function () {
+            if (!urlInput.value) {
+                return;
+            }
+            fetch("/info?url=" + encodeURIComponent(urlInput.value)).then(function (resp) {
+                return resp.ok ? resp.json() : null;
+            }).then(function (info) {
+                if (!info) {
+                    return;
+                }
+                formatSelect.innerHTML = '<option value="">best</option>';
+                codecSelect.innerHTML = '<option value="">any</option>';
+                var seenExt = {}, seenCodec = {};
+                (info.formats || []).forEach(function (f) {
+                    if (f.ext && !seenExt[f.ext]) {
+                        seenExt[f.ext] = true;
+                        formatSelect.innerHTML += '<option value="' + f.ext + '">' + f.ext + '</option>';
+                    }
+                    [f.vcodec, f.acodec].forEach(function (c) {
+                        if (c && c !== "none" && !seenCodec[c]) {
+                            seenCodec[c] = true;
+                            codecSelect.innerHTML += '<option value="' + c + '">' + c + '</option>';
+                        }
+                    });
+                });
+            }).catch(function () {});
+        });
+
+        document.getElementById("convertForm").addEventListener("submit", function (e) {
+            var form = e.target;
+            var id = Date.now().toString(36) + Math.random().toString(36).slice(2);
+            var hidden = document.createElement("input");
+            hidden.type = "hidden";
+            hidden.name = "progress";
+            hidden.value = id;
+            form.appendChild(hidden);
+
+            var progressEl = document.getElementById("progress");
+            var source = new EventSource("/progress/" + id);
+            source.addEventListener("progress", function (ev) {
+                var data = JSON.parse(ev.data);
+                progressEl.textContent = (data.bytes / (1024 * 1024)).toFixed(2) + " MB (" + data.elapsed.toFixed(1) + "s)";
+            });
+            source.addEventListener("done", function () {
+                progressEl.textContent = "Done";
+                source.close();
+            });
+            source.addEventListener("error", function () {
+                source.close();
+            });
+        });
+    </script>
 </body>
 </html>
 `
@@ -147,11 +261,41 @@ func (yh *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		debugLog = nopPrinter{}
 	}
 
+	// Correlate ffmpeg/yt-dlp log lines with the access log entry for this
+	// request when served behind LoggingMiddleware.
+	if requestID := RequestIDFromContext(r.Context()); requestID != "" {
+		infoLog = withRequestID(infoLog, requestID)
+		debugLog = withRequestID(debugLog, requestID)
+	}
+
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("X-XSS-Protection", "1; mode=block")
 
 	debugLog.Printf("%s Request %s %s", r.RemoteAddr, r.Method, r.URL.String())
 
+	if r.URL.Path == "/api/batch" {
+		yh.handleBatch(w, r)
+		return
+	} else if strings.HasPrefix(r.URL.Path, "/api/jobs/") {
+		yh.handleJob(w, r)
+		return
+	} else if strings.HasPrefix(r.URL.Path, "/progress/") {
+		yh.handleProgress(w, r)
+		return
+	} else if r.URL.Path == "/cache" {
+		yh.handleCacheList(w, r)
+		return
+	} else if strings.HasPrefix(r.URL.Path, "/cache/") {
+		yh.handleCacheDelete(w, r)
+		return
+	} else if r.URL.Path == "/info" || strings.HasPrefix(r.URL.Path, "/info/") {
+		yh.handleInfo(w, r)
+		return
+	} else if strings.HasPrefix(r.URL.Path, "/hls/") {
+		yh.handleHLS(w, r)
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -193,6 +337,40 @@ func (yh *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if requestOptions.Playlist {
+		yh.servePlaylist(w, r, requestOptions, infoLog)
+		return
+	}
+
+	cacheFormatName := ""
+	if requestOptions.Format != nil {
+		cacheFormatName = requestOptions.Format.Name
+	}
+	cacheKey := CacheKey(requestOptions.MediaRawURL, cacheFormatName, requestOptions.Codecs, requestOptions.Subs)
+
+	if yh.YDLS.Config.Cache != nil {
+		if rc, entry, ok := yh.YDLS.Config.Cache.Get(cacheKey); ok {
+			defer rc.Close()
+
+			w.Header().Set("ETag", entry.SHA)
+			if match := r.Header.Get("If-None-Match"); match == entry.SHA {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.Header().Set("Content-Type", entry.MIMEType)
+			if entry.Filename != "" {
+				w.Header().Set("Content-Disposition",
+					fmt.Sprintf("attachment; filename*=UTF-8''%s; filename=\"%s\"",
+						urlEncode(entry.Filename), safeContentDispositionFilename(entry.Filename)),
+				)
+			}
+			infoLog.Printf("%s Cache hit %s %s", r.RemoteAddr, r.Method, requestOptions.MediaRawURL)
+			io.Copy(w, rc)
+			return
+		}
+	}
+
 	downloadOptions := DownloadOptions{
 		RequestOptions: requestOptions,
 		BaseURL:        baseURLFromRequest(r, trustXHeaders),
@@ -200,6 +378,15 @@ func (yh *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Retries:        yh.YDLS.Config.DownloadRetries,
 	}
 
+	// A client-issued progress id lets the web form subscribe to
+	// GET /progress/{id} while this download runs. The id is registered
+	// here, so a subscription that arrives before this request gets a 404.
+	var prog *progress
+	if progressID := r.URL.Query().Get("progress"); progressID != "" {
+		prog = yh.progressReg().start(progressID)
+		downloadOptions.Progress = prog.update
+	}
+
 	formatName := "best"
 	if requestOptions.Format != nil {
 		formatName = requestOptions.Format.Name
@@ -212,9 +399,15 @@ func (yh *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	)
 	if err != nil {
 		infoLog.Printf("%s Download failed %s %s (%s)", r.RemoteAddr, r.Method, r.URL.Path, err.Error())
+		if prog != nil {
+			prog.finish(err)
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if prog != nil {
+		prog.setFilename(dr.Filename)
+	}
 
 	w.Header().Set("Content-Security-Policy", "default-src 'none'; reflected-xss block")
 	w.Header().Set("Content-Type", dr.MIMEType)
@@ -225,7 +418,33 @@ func (yh *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		)
 	}
 
-	io.Copy(w, dr.Media)
+	if yh.YDLS.Config.Cache != nil {
+		cacheTmp, err := os.CreateTemp("", "ydls-cache-*")
+		if err != nil {
+			io.Copy(w, dr.Media)
+		} else {
+			_, copyErr := io.Copy(io.MultiWriter(w, cacheTmp), dr.Media)
+			if copyErr != nil {
+				infoLog.Printf("%s Download copy failed, not caching %s (%s)", r.RemoteAddr, requestOptions.MediaRawURL, copyErr.Error())
+			} else {
+				cacheTmp.Seek(0, io.SeekStart)
+				if err := yh.YDLS.Config.Cache.Put(cacheKey, cacheTmp, CacheEntry{
+					MIMEType: dr.MIMEType,
+					Filename: dr.Filename,
+				}); err != nil {
+					infoLog.Printf("%s Cache store failed %s (%s)", r.RemoteAddr, requestOptions.MediaRawURL, err.Error())
+				}
+			}
+			cacheTmp.Close()
+			os.Remove(cacheTmp.Name())
+		}
+	} else {
+		io.Copy(w, dr.Media)
+	}
 	dr.Media.Close()
 	dr.Wait()
+
+	if prog != nil {
+		prog.finish(nil)
+	}
 }
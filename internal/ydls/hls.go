@@ -0,0 +1,311 @@
+package ydls
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wader/goutubedl"
+)
+
+const (
+	hlsSegmentDuration = 4 * time.Second
+	hlsStartupWait     = 300 * time.Millisecond
+)
+
+// hlsVariant is one entry in the master playlist's codec/bitrate ladder.
+type hlsVariant struct {
+	name      string
+	bandwidth int
+	dir       string // temp directory ffmpeg is segmenting into
+}
+
+// hlsSession is a single live HLS remux, keyed by a server-issued session ID
+// and torn down after IdleTimeout of inactivity.
+type hlsSession struct {
+	id       string
+	cancelFn context.CancelFunc
+	baseDir  string // parent of every variant's segment dir
+	variants []hlsVariant
+
+	mu         sync.Mutex
+	lastAccess time.Time
+}
+
+func (s *hlsSession) touch() {
+	s.mu.Lock()
+	s.lastAccess = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *hlsSession) idleFor() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastAccess)
+}
+
+// HLSSessions tracks in-progress HLS remuxes, freeing their ffmpeg
+// processes and temp segment directories once idle.
+type HLSSessions struct {
+	IdleTimeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*hlsSession
+}
+
+// NewHLSSessions starts a reaper that frees sessions idle for longer than
+// idleTimeout.
+func NewHLSSessions(idleTimeout time.Duration) *HLSSessions {
+	hs := &HLSSessions{IdleTimeout: idleTimeout, sessions: map[string]*hlsSession{}}
+	go hs.reap()
+	return hs
+}
+
+func (hs *HLSSessions) reap() {
+	ticker := time.NewTicker(hs.IdleTimeout / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		hs.mu.Lock()
+		for id, s := range hs.sessions {
+			if s.idleFor() > hs.IdleTimeout {
+				hs.free(id, s)
+			}
+		}
+		hs.mu.Unlock()
+	}
+}
+
+// free cancels the session's ffmpeg processes and removes its segment
+// directories. Caller must hold hs.mu.
+func (hs *HLSSessions) free(id string, s *hlsSession) {
+	s.cancelFn()
+	os.RemoveAll(s.baseDir)
+	delete(hs.sessions, id)
+}
+
+func (hs *HLSSessions) add(s *hlsSession) {
+	hs.mu.Lock()
+	hs.sessions[s.id] = s
+	hs.mu.Unlock()
+}
+
+func (hs *HLSSessions) get(id string) (*hlsSession, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	s, ok := hs.sessions[id]
+	return s, ok
+}
+
+// hlsCodecArgs picks ffmpeg's video/audio encoders for ro, honoring
+// ro.Format/ro.Codecs the same way the synchronous download pipeline
+// interprets them, instead of hardcoding libx264/aac for every variant.
+func hlsCodecArgs(ro RequestOptions) []string {
+	vcodec, acodec := "libx264", "aac"
+
+	wanted := append([]string{}, ro.Codecs...)
+	if ro.Format != nil {
+		wanted = append(wanted, ro.Format.Name)
+	}
+
+	for _, c := range wanted {
+		switch strings.ToLower(c) {
+		case "h264", "avc", "libx264":
+			vcodec = "libx264"
+		case "h265", "hevc", "libx265":
+			vcodec = "libx265"
+		case "vp8", "libvpx":
+			vcodec = "libvpx"
+		case "vp9", "libvpx-vp9":
+			vcodec = "libvpx-vp9"
+		case "aac":
+			acodec = "aac"
+		case "opus", "libopus":
+			acodec = "libopus"
+		case "mp3", "libmp3lame":
+			acodec = "libmp3lame"
+		case "vorbis", "libvorbis":
+			acodec = "libvorbis"
+		}
+	}
+
+	return []string{"-c:v", vcodec, "-c:a", acodec}
+}
+
+// startHLSVariant resolves mediaURL's direct media URL via goutubedl and
+// spawns ffmpeg to remux it into HLS segments under dir, matching the
+// container/codec options used by the synchronous download pipeline.
+func startHLSVariant(ctx context.Context, ro RequestOptions, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	result, err := goutubedl.New(ctx, ro.MediaRawURL, goutubedl.Options{})
+	if err != nil {
+		return err
+	}
+	sourceURL, err := result.DownloadURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-i", sourceURL}
+	args = append(args, hlsCodecArgs(ro)...)
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(int(hlsSegmentDuration.Seconds())),
+		"-hls_playlist_type", "event",
+		"-hls_segment_type", "mpegts",
+		"-hls_segment_filename", filepath.Join(dir, "segment_%d.ts"),
+		filepath.Join(dir, "playlist.m3u8"),
+	)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the process once ffmpeg exits or ctx cancels it, so it doesn't
+	// linger as a zombie for the life of the server.
+	go cmd.Wait()
+	return nil
+}
+
+// masterPlaylist builds the HLS master playlist referencing one media
+// playlist per variant in the ladder.
+func masterPlaylist(sessionID string, variants []hlsVariant) []byte {
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n")
+	for _, v := range variants {
+		fmt.Fprintf(&sb, "#EXT-X-STREAM-INF:BANDWIDTH=%d\n", v.bandwidth)
+		fmt.Fprintf(&sb, "/hls/%s/%s/playlist.m3u8\n", sessionID, v.name)
+	}
+	return []byte(sb.String())
+}
+
+// handleHLSMaster implements GET /hls/<opts>/<url>/master.m3u8: it starts a
+// new session transcoding one variant per entry in Config.Formats and
+// returns the master playlist once ffmpeg has had a moment to start
+// producing segments.
+func (yh *Handler) handleHLSMaster(w http.ResponseWriter, r *http.Request) {
+	if yh.HLS == nil {
+		http.Error(w, "HLS not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/hls/")
+	rest = strings.TrimSuffix(rest, "/master.m3u8")
+
+	optsURL := *r.URL
+	optsURL.Path = "/" + rest
+	requestOptions, err := NewRequestOptionsFromPath(&optsURL, yh.YDLS.Config.Formats)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+
+	baseDir, err := os.MkdirTemp("", "ydls-hls-")
+	if err != nil {
+		cancelFn()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	session := &hlsSession{id: newJobID(), cancelFn: cancelFn, baseDir: baseDir, lastAccess: time.Now()}
+
+	// BANDWIDTH is a rough per-rung estimate; Config.Formats doesn't carry a
+	// measured bitrate, so the ladder just fans out wider for each added
+	// format rather than ordering by true throughput.
+	const bandwidthStep = 1_200_000
+	for i, format := range yh.YDLS.Config.Formats {
+		variantOptions := requestOptions
+		variantOptions.Format = &format
+		dir := filepath.Join(baseDir, format.Name)
+
+		if err := startHLSVariant(ctx, variantOptions, dir); err != nil {
+			continue
+		}
+		session.variants = append(session.variants, hlsVariant{
+			name:      format.Name,
+			bandwidth: bandwidthStep * (i + 1),
+			dir:       dir,
+		})
+	}
+	if len(session.variants) == 0 {
+		cancelFn()
+		os.RemoveAll(baseDir)
+		http.Error(w, "No playable format", http.StatusBadRequest)
+		return
+	}
+
+	yh.HLS.add(session)
+
+	time.Sleep(hlsStartupWait)
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Write(masterPlaylist(session.id, session.variants))
+}
+
+// handleHLSSegment implements GET /hls/<sessionID>/<variant>/<file>, serving
+// either the per-variant media playlist or one of its .ts segments straight
+// from the temp directory ffmpeg is writing into.
+func (yh *Handler) handleHLSSegment(w http.ResponseWriter, r *http.Request, sessionID string) {
+	session, ok := yh.HLS.get(sessionID)
+	if !ok {
+		http.Error(w, "Unknown HLS session", http.StatusNotFound)
+		return
+	}
+	session.touch()
+
+	rest := strings.TrimPrefix(r.URL.Path, "/hls/"+sessionID+"/")
+	variantName, file, _ := strings.Cut(rest, "/")
+
+	var dir string
+	for _, v := range session.variants {
+		if v.name == variantName {
+			dir = v.dir
+			break
+		}
+	}
+	if dir == "" {
+		http.Error(w, "Unknown variant", http.StatusNotFound)
+		return
+	}
+
+	path := filepath.Join(dir, filepath.Base(file))
+	if strings.HasSuffix(file, ".ts") {
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Header().Set("Cache-Control", "max-age=86400")
+	} else {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeFile(w, r, path)
+}
+
+// handleHLS dispatches GET /hls/... to either the master playlist route or
+// a session's segment/media-playlist route.
+func (yh *Handler) handleHLS(w http.ResponseWriter, r *http.Request) {
+	if yh.HLS == nil {
+		http.Error(w, "HLS not enabled", http.StatusNotImplemented)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/master.m3u8") {
+		yh.handleHLSMaster(w, r)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/hls/")
+	sessionID, _, _ := strings.Cut(rest, "/")
+	if sessionID == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	yh.handleHLSSegment(w, r, sessionID)
+}
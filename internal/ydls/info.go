@@ -0,0 +1,182 @@
+package ydls
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wader/goutubedl"
+)
+
+// VideoFormatInfo is a single downloadable format as reported by yt-dlp,
+// without committing to actually downloading it.
+type VideoFormatInfo struct {
+	ID       string  `json:"id"`
+	Ext      string  `json:"ext"`
+	VCodec   string  `json:"vcodec"`
+	ACodec   string  `json:"acodec"`
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	TBR      float64 `json:"tbr,omitempty"`
+	Filesize int64   `json:"filesize,omitempty"`
+}
+
+// SubtitleInfo is a single available subtitle track.
+type SubtitleInfo struct {
+	Lang string `json:"lang"`
+	Ext  string `json:"ext"`
+}
+
+// ChapterInfo is a single chapter marker within the media.
+type ChapterInfo struct {
+	Title string  `json:"title"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// VideoInfo is the metadata-only view of a URL, returned by GET /info
+// without invoking ffmpeg.
+type VideoInfo struct {
+	Title       string            `json:"title"`
+	Uploader    string            `json:"uploader"`
+	Duration    float64           `json:"duration"`
+	Thumbnail   string            `json:"thumbnail"`
+	Description string            `json:"description"`
+	Formats     []VideoFormatInfo `json:"formats"`
+	Subtitles   []SubtitleInfo    `json:"subtitles"`
+	Chapters    []ChapterInfo     `json:"chapters"`
+}
+
+func videoInfoFromGoutubedl(info goutubedl.Info) VideoInfo {
+	vi := VideoInfo{
+		Title:       info.Title,
+		Uploader:    info.Uploader,
+		Duration:    info.Duration,
+		Thumbnail:   info.Thumbnail,
+		Description: info.Description,
+	}
+
+	for _, f := range info.Formats {
+		vi.Formats = append(vi.Formats, VideoFormatInfo{
+			ID:       f.FormatID,
+			Ext:      f.Ext,
+			VCodec:   f.VCodec,
+			ACodec:   f.ACodec,
+			Width:    int(f.Width),
+			Height:   int(f.Height),
+			TBR:      f.TBR,
+			Filesize: int64(f.Filesize),
+		})
+	}
+	for lang, tracks := range info.Subtitles {
+		for _, t := range tracks {
+			vi.Subtitles = append(vi.Subtitles, SubtitleInfo{Lang: lang, Ext: t.Ext})
+		}
+	}
+	for _, c := range info.Chapters {
+		vi.Chapters = append(vi.Chapters, ChapterInfo{Title: c.Title, Start: c.StartTime, End: c.EndTime})
+	}
+
+	return vi
+}
+
+// infoCacheEntry is a single TTL-bounded cached lookup.
+type infoCacheEntry struct {
+	info    VideoInfo
+	expires time.Time
+}
+
+// defaultInfoCacheTTL is used when Handler.InfoCacheTTL is unset.
+const defaultInfoCacheTTL = 10 * time.Minute
+
+// infoCache avoids hammering upstream with repeated /info lookups for the
+// same URL.
+type infoCache struct {
+	ttl time.Duration
+
+	mu sync.Mutex
+	m  map[string]infoCacheEntry
+}
+
+func newInfoCache(ttl time.Duration) *infoCache {
+	ic := &infoCache{ttl: ttl, m: map[string]infoCacheEntry{}}
+	go ic.reap()
+	return ic
+}
+
+// reap periodically drops expired entries so long-running servers don't
+// accumulate one entry per ever-looked-up URL.
+func (ic *infoCache) reap() {
+	ticker := time.NewTicker(ic.ttl)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		ic.mu.Lock()
+		for key, e := range ic.m {
+			if now.After(e.expires) {
+				delete(ic.m, key)
+			}
+		}
+		ic.mu.Unlock()
+	}
+}
+
+func (ic *infoCache) get(key string) (VideoInfo, bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	e, ok := ic.m[key]
+	if !ok || time.Now().After(e.expires) {
+		return VideoInfo{}, false
+	}
+	return e.info, true
+}
+
+func (ic *infoCache) put(key string, info VideoInfo) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	ic.m[key] = infoCacheEntry{info: info, expires: time.Now().Add(ic.ttl)}
+}
+
+// handleInfo implements GET /info?url=... (and GET /info/<url>), returning
+// available formats/subtitles/chapters for url without starting ffmpeg.
+func (yh *Handler) handleInfo(w http.ResponseWriter, r *http.Request) {
+	mediaURL := r.URL.Query().Get("url")
+	if mediaURL == "" && strings.HasPrefix(r.URL.Path, "/info/") {
+		// r.URL.Path never includes the "?...", so a media URL's own query
+		// string (e.g. YouTube's "?v=...") has to be reattached by hand
+		// before unescaping, the same way NewRequestOptionsFromPath's
+		// callers pass it the full r.URL rather than just r.URL.Path.
+		mediaURL = strings.TrimPrefix(r.URL.Path, "/info/")
+		if r.URL.RawQuery != "" {
+			mediaURL += "?" + r.URL.RawQuery
+		}
+		if u, err := url.QueryUnescape(mediaURL); err == nil {
+			mediaURL = u
+		}
+	}
+	if mediaURL == "" {
+		http.Error(w, "Missing url", http.StatusBadRequest)
+		return
+	}
+
+	cache := yh.infoCacheReg()
+	if info, ok := cache.get(mediaURL); ok {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+		return
+	}
+
+	result, err := goutubedl.New(r.Context(), mediaURL, goutubedl.Options{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info := videoInfoFromGoutubedl(result.Info)
+	cache.put(mediaURL, info)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
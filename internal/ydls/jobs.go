@@ -0,0 +1,462 @@
+package ydls
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobStatus is the current state of a queued download job.
+type JobStatus string
+
+// Job states, in the order a job normally moves through them.
+const (
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobError   JobStatus = "error"
+)
+
+// Job is a single download enqueued through POST /api/batch.
+type Job struct {
+	ID             string
+	RequestOptions RequestOptions
+
+	mu         sync.Mutex
+	Status     JobStatus
+	Err        error
+	Bytes      uint64
+	MIMEType   string
+	Filename   string
+	startedAt  time.Time
+	lastAccess time.Time
+
+	ctx      context.Context
+	cancelFn context.CancelFunc
+	// fileMu serializes reads of file across concurrent GET .../media
+	// requests (and Range retries), since http.ServeContent seeks on it.
+	fileMu sync.Mutex
+	file   *os.File
+}
+
+// touch records that the job was just looked at, so the reaper doesn't
+// expire it out from under an active poller.
+func (j *Job) touch() {
+	j.mu.Lock()
+	j.lastAccess = time.Now()
+	j.mu.Unlock()
+}
+
+// snapshot returns a consistent copy of the job's mutable state.
+func (j *Job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return Job{
+		ID:        j.ID,
+		Status:    j.Status,
+		Err:       j.Err,
+		Bytes:     j.Bytes,
+		MIMEType:  j.MIMEType,
+		Filename:  j.Filename,
+		startedAt: j.startedAt,
+	}
+}
+
+type jobByteCounter struct {
+	job *Job
+}
+
+func (c *jobByteCounter) Write(p []byte) (int, error) {
+	c.job.mu.Lock()
+	c.job.Bytes += uint64(len(p))
+	c.job.mu.Unlock()
+	return len(p), nil
+}
+
+const defaultJobTTL = 10 * time.Minute
+
+// Jobs is a bounded worker pool that runs downloads enqueued via the batch API,
+// keeping finished output on disk until the job expires. Fetching the output
+// does not remove it.
+type Jobs struct {
+	YDLS   YDLS
+	tmpDir string
+	TTL    time.Duration
+
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	queue chan *Job
+}
+
+// NewJobs starts a pool of workers workers pulling jobs from an internal
+// queue, and a reaper that drops finished (done or error) jobs idle for
+// longer than ttl (0 uses a default of 10 minutes), freeing their temp file.
+// Queued and running jobs are never dropped.
+func NewJobs(y YDLS, workers int, tmpDir string, ttl time.Duration) *Jobs {
+	if workers < 1 {
+		workers = 1
+	}
+	if ttl <= 0 {
+		ttl = defaultJobTTL
+	}
+
+	js := &Jobs{
+		YDLS:   y,
+		tmpDir: tmpDir,
+		TTL:    ttl,
+		jobs:   map[string]*Job{},
+		queue:  make(chan *Job, workers*4),
+	}
+	for i := 0; i < workers; i++ {
+		go js.worker()
+	}
+	go js.reap()
+
+	return js
+}
+
+// reap drops finished jobs idle for longer than js.TTL, closing and removing their
+// temp file so batch downloads don't leak disk and map entries forever.
+func (js *Jobs) reap() {
+	ticker := time.NewTicker(js.TTL / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		js.mu.Lock()
+		var expired []*Job
+		for id, j := range js.jobs {
+			j.mu.Lock()
+			idle := time.Since(j.lastAccess)
+			status := j.Status
+			j.mu.Unlock()
+
+			if status != JobDone && status != JobError {
+				continue
+			}
+			if idle < js.TTL {
+				continue
+			}
+
+			expired = append(expired, j)
+			delete(js.jobs, id)
+		}
+		js.mu.Unlock()
+
+		// closeFile can block on fileMu for as long as a concurrent
+		// handleJobMedia is still streaming that job's file, so it must
+		// run after js.mu is released to avoid stalling every other
+		// Submit/Get/Cancel call for the duration of that transfer.
+		for _, j := range expired {
+			j.closeFile()
+		}
+	}
+}
+
+// closeFile closes and removes the job's temp file, if any.
+func (j *Job) closeFile() {
+	j.fileMu.Lock()
+	defer j.fileMu.Unlock()
+
+	j.mu.Lock()
+	f := j.file
+	j.file = nil
+	j.mu.Unlock()
+
+	if f != nil {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Submit enqueues a new download job and returns it without waiting for the
+// download to run. It blocks while the job queue is full.
+func (js *Jobs) Submit(ro RequestOptions) *Job {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	j := &Job{
+		ID:             newJobID(),
+		Status:         JobQueued,
+		RequestOptions: ro,
+		ctx:            ctx,
+		cancelFn:       cancelFn,
+	}
+
+	js.mu.Lock()
+	js.jobs[j.ID] = j
+	js.mu.Unlock()
+
+	js.queue <- j
+
+	return j
+}
+
+// Get returns the job with id, if any.
+func (js *Jobs) Get(id string) (*Job, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	j, ok := js.jobs[id]
+	return j, ok
+}
+
+// Cancel cancels the job with id via its stored context.CancelFunc.
+func (js *Jobs) Cancel(id string) bool {
+	js.mu.Lock()
+	j, ok := js.jobs[id]
+	js.mu.Unlock()
+	if !ok {
+		return false
+	}
+	j.cancelFn()
+	return true
+}
+
+func (js *Jobs) worker() {
+	for j := range js.queue {
+		js.run(j)
+	}
+}
+
+func (js *Jobs) run(j *Job) {
+	j.mu.Lock()
+	j.Status = JobRunning
+	j.startedAt = time.Now()
+	j.lastAccess = time.Now()
+	j.mu.Unlock()
+
+	dr, err := js.YDLS.Download(j.ctx, DownloadOptions{RequestOptions: j.RequestOptions})
+	if err != nil {
+		j.mu.Lock()
+		j.Status = JobError
+		j.Err = err
+		j.mu.Unlock()
+		return
+	}
+
+	f, err := os.CreateTemp(js.tmpDir, "ydls-job-")
+	if err != nil {
+		dr.Media.Close()
+		dr.Wait()
+		j.mu.Lock()
+		j.Status = JobError
+		j.Err = err
+		j.mu.Unlock()
+		return
+	}
+
+	j.mu.Lock()
+	j.MIMEType = dr.MIMEType
+	j.Filename = dr.Filename
+	j.mu.Unlock()
+
+	_, copyErr := io.Copy(io.MultiWriter(f, &jobByteCounter{job: j}), dr.Media)
+	dr.Media.Close()
+	dr.Wait()
+
+	if copyErr != nil {
+		f.Close()
+		os.Remove(f.Name())
+		j.mu.Lock()
+		j.Status = JobError
+		j.Err = copyErr
+		j.mu.Unlock()
+		return
+	}
+	f.Seek(0, io.SeekStart)
+
+	j.mu.Lock()
+	j.file = f
+	j.Status = JobDone
+	j.mu.Unlock()
+}
+
+type batchObjectRequest struct {
+	URL    string   `json:"url"`
+	Format string   `json:"format"`
+	Codecs []string `json:"codecs"`
+}
+
+type batchRequest struct {
+	Operation string               `json:"operation"`
+	Objects   []batchObjectRequest `json:"objects"`
+}
+
+type jobLinks struct {
+	Self     string `json:"self"`
+	Download string `json:"download"`
+	Cancel   string `json:"cancel"`
+}
+
+type batchObjectResponse struct {
+	ID    string   `json:"id,omitempty"`
+	Error string   `json:"error,omitempty"`
+	Links jobLinks `json:"_links,omitempty"`
+}
+
+type batchResponse struct {
+	Objects []batchObjectResponse `json:"objects"`
+}
+
+type jobStatusResponse struct {
+	ID          string    `json:"id"`
+	Status      JobStatus `json:"status"`
+	Bytes       uint64    `json:"bytes_transferred"`
+	ETASeconds  float64   `json:"eta_seconds,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Filename    string    `json:"filename,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// handleBatch implements POST /api/batch, modeled on the Git-LFS batch API:
+// it accepts a list of download objects and returns a job per object.
+func (yh *Handler) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if yh.Jobs == nil {
+		http.Error(w, "Batch API not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	var br batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&br); err != nil {
+		http.Error(w, "Invalid batch request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if br.Operation != "download" {
+		http.Error(w, "Unsupported operation "+br.Operation, http.StatusBadRequest)
+		return
+	}
+
+	baseURL := baseURLFromRequest(r, trustXHeaders)
+	resp := batchResponse{Objects: make([]batchObjectResponse, 0, len(br.Objects))}
+
+	for _, o := range br.Objects {
+		q := url.Values{"url": {o.URL}}
+		if o.Format != "" {
+			q.Set("format", o.Format)
+		}
+		if len(o.Codecs) > 0 {
+			q["codec"] = o.Codecs
+		}
+
+		ro, roErr := NewRequestOptionsFromQuery(q, yh.YDLS.Config.Formats)
+		if roErr != nil {
+			resp.Objects = append(resp.Objects, batchObjectResponse{Error: roErr.Error()})
+			continue
+		}
+
+		j := yh.Jobs.Submit(ro)
+		resp.Objects = append(resp.Objects, batchObjectResponse{
+			ID: j.ID,
+			Links: jobLinks{
+				Self:     fmt.Sprintf("%s/api/jobs/%s", baseURL, j.ID),
+				Download: fmt.Sprintf("%s/api/jobs/%s/media", baseURL, j.ID),
+				Cancel:   fmt.Sprintf("%s/api/jobs/%s", baseURL, j.ID),
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleJob dispatches GET/DELETE /api/jobs/{id} and GET /api/jobs/{id}/media.
+func (yh *Handler) handleJob(w http.ResponseWriter, r *http.Request) {
+	if yh.Jobs == nil {
+		http.Error(w, "Batch API not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+	id, sub, _ := strings.Cut(rest, "/")
+
+	j, ok := yh.Jobs.Get(id)
+	if !ok {
+		http.Error(w, "Unknown job", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case sub == "media" && r.Method == http.MethodGet:
+		yh.handleJobMedia(w, r, j)
+	case sub == "" && r.Method == http.MethodDelete:
+		yh.Jobs.Cancel(id)
+		w.WriteHeader(http.StatusNoContent)
+	case sub == "" && r.Method == http.MethodGet:
+		yh.handleJobStatus(w, j)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (yh *Handler) handleJobStatus(w http.ResponseWriter, j *Job) {
+	j.touch()
+	s := j.snapshot()
+
+	resp := jobStatusResponse{
+		ID:          s.ID,
+		Status:      s.Status,
+		Bytes:       s.Bytes,
+		ContentType: s.MIMEType,
+		Filename:    s.Filename,
+	}
+	if s.Err != nil {
+		resp.Error = s.Err.Error()
+	}
+	// ETASeconds is left unset: the download pipeline doesn't expose an
+	// expected size to estimate against.
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (yh *Handler) handleJobMedia(w http.ResponseWriter, r *http.Request, j *Job) {
+	j.touch()
+	s := j.snapshot()
+
+	if s.Status != JobDone {
+		if s.Status == JobError {
+			http.Error(w, s.Err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// fileMu serializes concurrent GETs (and Range retries) against the
+	// shared *os.File, since http.ServeContent seeks on it.
+	j.fileMu.Lock()
+	defer j.fileMu.Unlock()
+
+	j.mu.Lock()
+	f := j.file
+	j.mu.Unlock()
+	if f == nil {
+		http.Error(w, "Media expired", http.StatusGone)
+		return
+	}
+
+	w.Header().Set("Content-Type", s.MIMEType)
+	if s.Filename != "" {
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf("attachment; filename*=UTF-8''%s; filename=\"%s\"",
+				urlEncode(s.Filename), safeContentDispositionFilename(s.Filename)),
+		)
+	}
+	http.ServeContent(w, r, s.Filename, s.startedAt, f)
+}
@@ -0,0 +1,237 @@
+package ydls
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/wader/goutubedl"
+)
+
+// ArchiveFormat selects the container used to bundle a playlist download.
+type ArchiveFormat string
+
+// Supported archive formats.
+const (
+	ArchiveZip ArchiveFormat = "zip"
+	ArchiveTar ArchiveFormat = "tar"
+)
+
+const defaultPlaylistWorkers = 4
+
+// PlaylistEntry is a single item resolved from a flat-playlist listing.
+type PlaylistEntry struct {
+	Index int
+	URL   string
+	Title string
+}
+
+// PlaylistEntries enumerates playlistURL via goutubedl's flat-playlist mode,
+// without downloading any media.
+func PlaylistEntries(ctx context.Context, playlistURL string) (title string, entries []PlaylistEntry, err error) {
+	result, err := goutubedl.New(ctx, playlistURL, goutubedl.Options{Type: goutubedl.TypePlaylist})
+	if err != nil {
+		return "", nil, err
+	}
+
+	title = result.Info.Title
+	for i, e := range result.Info.Entries {
+		entries = append(entries, PlaylistEntry{Index: i, URL: e.WebpageURL, Title: e.Title})
+	}
+
+	return title, entries, nil
+}
+
+// PlaylistResult is one playlist entry's finished download, or the error
+// that entry failed with.
+type PlaylistResult struct {
+	Entry    PlaylistEntry
+	Filename string
+	file     *os.File
+	Err      error
+}
+
+// DownloadPlaylistEntries runs ro against each entry in parallel, bounded by
+// workers, downloading each into a temp file so that entries can transcode
+// concurrently even though the final archive is written sequentially. It
+// returns only once every entry has finished. Per-entry failures are
+// recorded on the result rather than aborting the rest of the playlist.
+func DownloadPlaylistEntries(ctx context.Context, y YDLS, ro RequestOptions, entries []PlaylistEntry, workers int) []PlaylistResult {
+	if workers < 1 {
+		workers = defaultPlaylistWorkers
+	}
+
+	results := make([]PlaylistResult, len(entries))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for i, e := range entries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, e PlaylistEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = downloadPlaylistEntry(ctx, y, ro, e)
+		}(i, e)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func downloadPlaylistEntry(ctx context.Context, y YDLS, ro RequestOptions, e PlaylistEntry) PlaylistResult {
+	entryOptions := ro
+	entryOptions.MediaRawURL = e.URL
+	entryOptions.Playlist = false
+
+	dr, err := y.Download(ctx, DownloadOptions{RequestOptions: entryOptions})
+	if err != nil {
+		return PlaylistResult{Entry: e, Err: err}
+	}
+
+	f, err := os.CreateTemp("", "ydls-playlist-*")
+	if err != nil {
+		dr.Media.Close()
+		dr.Wait()
+		return PlaylistResult{Entry: e, Err: err}
+	}
+
+	_, copyErr := io.Copy(f, dr.Media)
+	dr.Media.Close()
+	dr.Wait()
+	if copyErr != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return PlaylistResult{Entry: e, Err: copyErr}
+	}
+	f.Seek(0, io.SeekStart)
+
+	return PlaylistResult{Entry: e, Filename: dr.Filename, file: f}
+}
+
+// archiveEntryName picks a stable, filesystem-safe name for an entry inside
+// the archive, preferring its downloaded filename over the playlist title.
+func archiveEntryName(r PlaylistResult) string {
+	name := r.Filename
+	if name == "" {
+		name = r.Entry.Title
+	}
+	if name == "" {
+		name = fmt.Sprintf("entry_%03d", r.Entry.Index+1)
+	}
+	return fmt.Sprintf("%03d_%s", r.Entry.Index+1, safeContentDispositionFilename(name))
+}
+
+// WriteArchive bundles results into w as format, appending a trailing
+// errors.txt entry for any that failed instead of aborting the response.
+func WriteArchive(w io.Writer, format ArchiveFormat, results []PlaylistResult) error {
+	// Every entry's temp file is closed and removed here on return, so an
+	// early return (a write error, a full disk) does not leak the fds and
+	// temp files of entries the loop never reached.
+	defer func() {
+		for _, res := range results {
+			if res.file != nil {
+				res.file.Close()
+				os.Remove(res.file.Name())
+			}
+		}
+	}()
+
+	var errLines []string
+
+	if format == ArchiveTar {
+		tw := tar.NewWriter(w)
+		for _, res := range results {
+			if res.Err != nil {
+				errLines = append(errLines, fmt.Sprintf("%s: %s", res.Entry.URL, res.Err))
+				continue
+			}
+
+			fi, err := res.file.Stat()
+			if err == nil {
+				err = tw.WriteHeader(&tar.Header{Name: archiveEntryName(res), Size: fi.Size(), Mode: 0644})
+			}
+			if err == nil {
+				_, err = io.Copy(tw, res.file)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if len(errLines) > 0 {
+			data := []byte(strings.Join(errLines, "\n") + "\n")
+			tw.WriteHeader(&tar.Header{Name: "errors.txt", Size: int64(len(data)), Mode: 0644})
+			tw.Write(data)
+		}
+		return tw.Close()
+	}
+
+	zw := zip.NewWriter(w)
+	for _, res := range results {
+		if res.Err != nil {
+			errLines = append(errLines, fmt.Sprintf("%s: %s", res.Entry.URL, res.Err))
+			continue
+		}
+
+		zf, err := zw.Create(archiveEntryName(res))
+		if err == nil {
+			_, err = io.Copy(zf, res.file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(errLines) > 0 {
+		if zf, err := zw.Create("errors.txt"); err == nil {
+			fmt.Fprint(zf, strings.Join(errLines, "\n")+"\n")
+		}
+	}
+	return zw.Close()
+}
+
+// SafeArchiveFilename makes title safe to use as a downloaded archive's
+// filename.
+func SafeArchiveFilename(title string) string {
+	if title == "" {
+		return "playlist"
+	}
+	return safeContentDispositionFilename(title)
+}
+
+// servePlaylist implements the playlist branch of Handler.ServeHTTP: it
+// enumerates ro's playlist URL and streams the transcoded entries back as
+// a single archive.
+func (yh *Handler) servePlaylist(w http.ResponseWriter, r *http.Request, ro RequestOptions, infoLog Printer) {
+	format := ArchiveZip
+	contentType, ext := "application/zip", "zip"
+	if r.URL.Query().Get("archive") == "tar" {
+		format, contentType, ext = ArchiveTar, "application/x-tar", "tar"
+	}
+
+	workers := yh.YDLS.Config.Workers
+	if workers < 1 {
+		workers = defaultPlaylistWorkers
+	}
+
+	title, entries, err := PlaylistEntries(r.Context(), ro.MediaRawURL)
+	if err != nil {
+		infoLog.Printf("%s Playlist lookup failed %s (%s)", r.RemoteAddr, ro.MediaRawURL, err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := DownloadPlaylistEntries(r.Context(), yh.YDLS, ro, entries, workers)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", SafeArchiveFilename(title), ext))
+
+	if err := WriteArchive(w, format, results); err != nil {
+		infoLog.Printf("%s Playlist archive failed %s (%s)", r.RemoteAddr, ro.MediaRawURL, err.Error())
+	}
+}
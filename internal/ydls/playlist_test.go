@@ -0,0 +1,76 @@
+package ydls
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestArchiveEntryName(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		r    PlaylistResult
+		want string
+	}{
+		{
+			name: "prefers downloaded filename",
+			r:    PlaylistResult{Entry: PlaylistEntry{Index: 0}, Filename: "clip.mp4"},
+			want: "001_clip.mp4",
+		},
+		{
+			name: "falls back to entry title",
+			r:    PlaylistResult{Entry: PlaylistEntry{Index: 1, Title: "My Clip"}},
+			want: "002_My Clip",
+		},
+		{
+			name: "falls back to index when nothing else is known",
+			r:    PlaylistResult{Entry: PlaylistEntry{Index: 2}},
+			want: "003_entry_003",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := archiveEntryName(tc.r); got != tc.want {
+				t.Errorf("archiveEntryName() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteArchiveZipRecordsErrors(t *testing.T) {
+	results := []PlaylistResult{
+		{Entry: PlaylistEntry{Index: 0, URL: "http://example.com/bad"}, Err: errors.New("download failed")},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, ArchiveZip, results); err != nil {
+		t.Fatalf("WriteArchive() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+
+	var found bool
+	for _, f := range zr.File {
+		if f.Name != "errors.txt" {
+			continue
+		}
+		found = true
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("errors.txt Open() error = %v", err)
+		}
+		defer rc.Close()
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(rc)
+		if !strings.Contains(buf.String(), "download failed") {
+			t.Errorf("errors.txt = %q, want it to mention the failure", buf.String())
+		}
+	}
+	if !found {
+		t.Error("WriteArchive() produced no errors.txt entry for a failed result")
+	}
+}
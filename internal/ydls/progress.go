@@ -0,0 +1,155 @@
+package ydls
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progress tracks the live byte count of a single in-flight download so it
+// can be polled by an SSE subscriber, keyed by a request-issued job ID.
+type progress struct {
+	mu         sync.Mutex
+	bytes      uint64
+	filename   string
+	started    time.Time
+	done       bool
+	finishedAt time.Time
+	err        error
+}
+
+func (p *progress) update(bytes uint64) {
+	p.mu.Lock()
+	p.bytes = bytes
+	p.mu.Unlock()
+}
+
+func (p *progress) setFilename(filename string) {
+	p.mu.Lock()
+	p.filename = filename
+	p.mu.Unlock()
+}
+
+func (p *progress) finish(err error) {
+	p.mu.Lock()
+	p.done = true
+	p.finishedAt = time.Now()
+	p.err = err
+	p.mu.Unlock()
+}
+
+func (p *progress) snapshot() progress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return progress{bytes: p.bytes, filename: p.filename, started: p.started, done: p.done, finishedAt: p.finishedAt, err: p.err}
+}
+
+// defaultProgressTTL bounds how long a finished progress entry is kept for
+// an SSE subscriber that never connects (or never finishes draining it).
+const defaultProgressTTL = 10 * time.Minute
+
+// progressRegistry is an in-memory map of progress trackers keyed by
+// request-issued job ID, used by the /progress/{id} SSE endpoint.
+type progressRegistry struct {
+	ttl time.Duration
+
+	mu sync.Mutex
+	m  map[string]*progress
+}
+
+func newProgressRegistry() *progressRegistry {
+	pr := &progressRegistry{ttl: defaultProgressTTL, m: map[string]*progress{}}
+	go pr.reap()
+	return pr
+}
+
+// reap drops finished entries older than pr.ttl, so a subscriber that never
+// connects (or never gets to drain the terminal event) doesn't leak an
+// entry for the life of the server. In-flight entries are left alone: the
+// download that owns them always calls finish() once it completes, so
+// they're swept on a later pass instead.
+func (pr *progressRegistry) reap() {
+	ticker := time.NewTicker(pr.ttl / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pr.mu.Lock()
+		for id, p := range pr.m {
+			s := p.snapshot()
+			if s.done && time.Since(s.finishedAt) > pr.ttl {
+				delete(pr.m, id)
+			}
+		}
+		pr.mu.Unlock()
+	}
+}
+
+func (pr *progressRegistry) start(id string) *progress {
+	p := &progress{started: time.Now()}
+	pr.mu.Lock()
+	pr.m[id] = p
+	pr.mu.Unlock()
+	return p
+}
+
+func (pr *progressRegistry) get(id string) (*progress, bool) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	p, ok := pr.m[id]
+	return p, ok
+}
+
+func (pr *progressRegistry) remove(id string) {
+	pr.mu.Lock()
+	delete(pr.m, id)
+	pr.mu.Unlock()
+}
+
+// handleProgress implements GET /progress/{id}, a text/event-stream of the
+// named download's byte count until it completes or errors.
+func (yh *Handler) handleProgress(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/progress/")
+	p, ok := yh.progressReg().get(id)
+	if !ok {
+		http.Error(w, "Unknown progress id", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			s := p.snapshot()
+			if s.done {
+				if s.err != nil {
+					fmt.Fprintf(w, "event: error\ndata: %s\n\n", s.err.Error())
+				} else {
+					fmt.Fprintf(w, "event: done\ndata: {\"bytes\":%d,\"filename\":%q}\n\n", s.bytes, s.filename)
+				}
+				flusher.Flush()
+				yh.progressReg().remove(id)
+				return
+			}
+
+			fmt.Fprintf(w, "event: progress\ndata: {\"bytes\":%d,\"elapsed\":%.1f,\"filename\":%q}\n\n",
+				s.bytes, time.Since(s.started).Seconds(), s.filename)
+			flusher.Flush()
+		}
+	}
+}